@@ -7,20 +7,6 @@ import (
 	"strings"
 )
 
-var (
-	htmlEscaper = strings.NewReplacer(
-		`&`, "&amp;",
-		`<`, "&lt;",
-		`>`, "&gt;",
-	)
-	attrEscaper = strings.NewReplacer(
-		`&`, "&amp;",
-		`<`, "&lt;",
-		`>`, "&gt;",
-		`"`, "&#34;",
-	)
-)
-
 const (
 	DoctypeHTML5               = "html"
 	DoctypeHTML4Strict         = `HTML PUBLIC "-//W3C//DTD HTML 4.01//EN" "http://www.w3.org/TR/html4/strict.dtd"`
@@ -40,17 +26,42 @@ func (b blank) String() string {
 	return ""
 }
 
+// elementFrame tracks a single open element: the name it was emitted under,
+// the namespace prefixes that are bound and visible from inside it, and any
+// NS() catalog entries that were overwritten while it was the innermost
+// open element, so End can restore them for the rest of the frame's own
+// scope (e.g. a sibling built after this element closes).
+type elementFrame struct {
+	name      string
+	bound     map[string]string // prefix -> namespace URI visible inside this element
+	nsRestore []nsRestore
+}
+
+// nsRestore records the catalog value a prefix had in b.namespaces before a
+// nested NS() call overwrote it, so the overwrite can be undone once the
+// element it was scoped to closes.
+type nsRestore struct {
+	prefix   string
+	hadValue bool
+	value    string
+}
+
 type Builder struct {
 	writer          io.Writer
 	buildingElement bool
 	attributes      map[string]string
 	attrnames       []string
-	elements        []string
+	elements        []elementFrame
 	indentString    string
 	indent          string
 	offset          int  // indent offset
 	empty           bool // use empty elements
 	inline          int
+	namespaces      map[string]string // prefix -> URI catalog registered through NS
+	nsAtRoot        bool              // declare all registered namespaces on the root element
+	strict          bool              // validate names/CDATA payloads, see Strict
+	invalidChar     CharPolicy        // how EscapeText handles invalid input, see OnInvalidChar
+	err             error             // first error recorded by Strict, OnInvalidChar(Error) or an unregistered NS prefix, see Err
 }
 
 func s(v ...interface{}) string {
@@ -87,14 +98,17 @@ func (b *Builder) EndInline() *Builder {
 	return b
 }
 
-// Element defines a new element in the xml document.
-func (b *Builder) Element(element string, args ...interface{}) *Builder {
+// Element defines a new element in the xml document. element is usually a
+// plain string, but an xml.Name{Space, Local} is also accepted: Space is
+// resolved against the namespaces registered with NS and rendered as the
+// matching prefix (see ElementNS for an explicit variant).
+func (b *Builder) Element(element interface{}, args ...interface{}) *Builder {
 	if b.buildingElement {
 		b.outputElement(false)
 	}
 
 	b.buildingElement = true
-	b.elements = append(b.elements, element)
+	b.resolveName(element)
 	first := len(args) % 2
 	for i := first; i < len(args); i += 2 {
 		if a, v := s(args[i+0]), s(args[i+1]); a != "" && (v != "" || args[i+1] == Blank) {
@@ -109,13 +123,13 @@ func (b *Builder) Element(element string, args ...interface{}) *Builder {
 }
 
 // ElementNoEscape defines a new element in the xml document but doesn't escape the Chars
-func (b *Builder) ElementNoEscape(element string, args ...interface{}) *Builder {
+func (b *Builder) ElementNoEscape(element interface{}, args ...interface{}) *Builder {
 	if b.buildingElement {
 		b.outputElement(false)
 	}
 
 	b.buildingElement = true
-	b.elements = append(b.elements, element)
+	b.resolveName(element)
 	first := len(args) % 2
 	for i := first; i < len(args); i += 2 {
 		if a, v := s(args[i+0]), s(args[i+1]); a != "" && (v != "" || args[i+1] == Blank) {
@@ -130,12 +144,21 @@ func (b *Builder) ElementNoEscape(element string, args ...interface{}) *Builder
 }
 
 // Attr will add an attribute to the current element being build, or when not building
-// an element it will add attributes to the next element to be build.
-func (b *Builder) Attr(name string, value interface{}) *Builder {
-	if _, found := b.attributes[name]; !found {
-		b.attrnames = append(b.attrnames, name)
+// an element it will add attributes to the next element to be build. name is
+// usually a plain string, but an xml.Name{Space, Local} is also accepted:
+// Space is resolved against the namespaces registered with NS (see AttrNS
+// for an explicit variant).
+func (b *Builder) Attr(name interface{}, value interface{}) *Builder {
+	attrName := b.resolveAttrName(name)
+	if b.strict {
+		if err := validateName(attrName); err != nil {
+			b.fail(err)
+		}
 	}
-	b.attributes[name] = s(value)
+	if _, found := b.attributes[attrName]; !found {
+		b.attrnames = append(b.attrnames, attrName)
+	}
+	b.attributes[attrName] = s(value)
 
 	return b
 }
@@ -145,12 +168,14 @@ func (b *Builder) End() *Builder {
 	if b.buildingElement {
 		b.outputElement(true)
 	} else {
+		frame := b.elements[len(b.elements)-1]
 		if b.inline > 0 {
-			fmt.Fprint(b.writer, "</", b.elements[len(b.elements)-1], ">")
+			fmt.Fprint(b.writer, "</", frame.name, ">")
 		} else {
-			fmt.Fprint(b.writer, b.doIndent(), "</", b.elements[len(b.elements)-1], ">\n")
+			fmt.Fprint(b.writer, b.doIndent(), "</", frame.name, ">\n")
 		}
 		b.elements = b.elements[:len(b.elements)-1]
+		b.restoreNamespaces(frame)
 	}
 	return b
 }
@@ -175,7 +200,7 @@ func (b *Builder) TagNoEscape(element string, args ...interface{}) *Builder {
 func (b *Builder) Instruct(name string, args ...interface{}) *Builder {
 	fmt.Fprint(b.writer, "<?", name)
 	for i := 0; i < len(args); i += 2 {
-		fmt.Fprint(b.writer, " ", args[i+0], `="`, attrEscaper.Replace(s(args[i+1])), `"`)
+		fmt.Fprint(b.writer, " ", args[i+0], `="`, b.escapeString(s(args[i+1])), `"`)
 	}
 	fmt.Fprintln(b.writer, "?>")
 	return b
@@ -203,9 +228,9 @@ func (b *Builder) Chars(chars ...interface{}) *Builder {
 	b.outputElement(false)
 	line := fmt.Sprint(chars...)
 	if b.inline > 0 {
-		fmt.Fprint(b.writer, htmlEscaper.Replace(line))
+		fmt.Fprint(b.writer, b.escapeCharData(line))
 	} else {
-		fmt.Fprint(b.writer, b.doIndent(), b.indent, htmlEscaper.Replace(line), "\n")
+		fmt.Fprint(b.writer, b.doIndent(), b.indent, b.escapeCharData(line), "\n")
 	}
 	return b
 }
@@ -222,11 +247,15 @@ func (b *Builder) CharsNoEscape(chars ...interface{}) *Builder {
 	return b
 }
 
-// Cdata adds a cdata element to the output. The cdata endtoken "]]> should not appear in the input string.
-// This function does not check this.
+// Cdata adds a cdata element to the output. The cdata endtoken "]]>" should
+// not appear in the input string; in Strict mode this is checked and
+// recorded as an error (see Err), but by default it is not.
 func (b *Builder) Cdata(data ...interface{}) *Builder {
 	b.outputElement(false)
 	line := fmt.Sprint(data...)
+	if b.strict && strings.Contains(line, "]]>") {
+		b.fail(fmt.Errorf("xmlbuilder: CDATA payload contains \"]]>\""))
+	}
 	if b.inline > 0 {
 		fmt.Fprint(b.writer, "<![CDATA[", line, "]]>")
 	} else {
@@ -260,23 +289,25 @@ func (b *Builder) Empty(useEmpty bool) *Builder {
 func (b *Builder) outputElement(close bool) {
 	if b.buildingElement {
 		buf := &bytes.Buffer{}
+		frame := b.elements[len(b.elements)-1]
 		if b.inline == 0 {
 			buf.WriteString(b.doIndent())
 		}
 		buf.WriteRune('<')
-		buf.WriteString(b.elements[len(b.elements)-1])
+		buf.WriteString(frame.name)
 		for _, key := range b.attrnames {
 			value := b.attributes[key]
 			buf.WriteRune(' ')
 			buf.WriteString(key)
 			buf.WriteString(`="`)
-			buf.WriteString(attrEscaper.Replace(value))
+			buf.WriteString(b.escapeString(value))
 			buf.WriteString(`"`)
 		}
 		b.attributes = make(map[string]string)
 		b.attrnames = b.attrnames[:0]
 		if close {
 			b.elements = b.elements[:len(b.elements)-1]
+			b.restoreNamespaces(frame)
 			if b.empty {
 				buf.WriteString(" />")
 			} else {
@@ -0,0 +1,203 @@
+package xmlbuilder
+
+import "io"
+
+// Document is a DOM-style sibling to the streaming Builder: instead of
+// writing tags as they are built, it keeps an in-memory Element tree that
+// can be inspected, reordered or patched before being rendered with
+// WriteTo, which reuses the same Builder that Element/Attr/Chars/End are
+// built on, so the output follows the same indent/empty rules as the
+// streaming API.
+type Document struct {
+	Children []*Element // top-level nodes, conventionally a single root element
+	indent   string
+	empty    bool
+}
+
+// NewDocument creates an empty document. Like New, pretty printing is
+// enabled by default with a two space indent.
+func NewDocument() *Document {
+	return &Document{indent: "  ", empty: true}
+}
+
+// CreateElement creates a new top-level element and appends it to the
+// document.
+func (d *Document) CreateElement(tag string) *Element {
+	e := &Element{Tag: tag, doc: d}
+	d.Children = append(d.Children, e)
+	return e
+}
+
+// Root returns the document's first top-level element, or nil if none has
+// been created yet.
+func (d *Document) Root() *Element {
+	if len(d.Children) == 0 {
+		return nil
+	}
+	return d.Children[0]
+}
+
+// Indent sets the indent string used when rendering, mirroring Builder.Indent.
+func (d *Document) Indent(indent string) *Document {
+	d.indent = indent
+	return d
+}
+
+// Empty controls whether empty elements render as <tag /> or <tag>,
+// mirroring Builder.Empty.
+func (d *Document) Empty(useEmpty bool) *Document {
+	d.empty = useEmpty
+	return d
+}
+
+// FindElements evaluates an XPath-lite path against the document's root
+// element. See Element.FindElements for the supported syntax.
+func (d *Document) FindElements(path string) []*Element {
+	root := d.Root()
+	if root == nil {
+		return nil
+	}
+	return root.FindElements(path)
+}
+
+// SelectElement returns the document root's first direct child with the
+// given tag, or nil if there is none.
+func (d *Document) SelectElement(tag string) *Element {
+	root := d.Root()
+	if root == nil {
+		return nil
+	}
+	return root.SelectElement(tag)
+}
+
+// WriteTo renders the document through a Builder configured with the
+// document's Indent/Empty settings, satisfying io.WriterTo.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	b := New(cw)
+	b.Indent(d.indent)
+	b.Empty(d.empty)
+	for _, child := range d.Children {
+		child.render(b)
+	}
+	return cw.n, nil
+}
+
+// countingWriter tallies bytes written so Document.WriteTo can satisfy
+// io.WriterTo without Builder itself tracking a byte count.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Attr is a single name/value attribute on an Element.
+type Attr struct {
+	Name  string
+	Value string
+}
+
+// Element is a single node in a Document tree. Unlike the streaming
+// Builder, it can be freely inspected and mutated before being rendered.
+type Element struct {
+	Tag      string
+	Attrs    []Attr
+	Children []*Element
+	Text     string
+
+	parent *Element
+	doc    *Document
+}
+
+// CreateElement creates a new child element and appends it to e.
+func (e *Element) CreateElement(tag string) *Element {
+	child := &Element{Tag: tag, parent: e, doc: e.doc}
+	e.Children = append(e.Children, child)
+	return child
+}
+
+// CreateAttr adds an attribute to e and returns e for chaining.
+func (e *Element) CreateAttr(name, value string) *Element {
+	e.Attrs = append(e.Attrs, Attr{Name: name, Value: value})
+	return e
+}
+
+// SetText sets e's character data content and returns e for chaining.
+func (e *Element) SetText(text string) *Element {
+	e.Text = text
+	return e
+}
+
+// Attr returns the value of the named attribute and whether it was present.
+func (e *Element) Attr(name string) (string, bool) {
+	for _, a := range e.Attrs {
+		if a.Name == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// Parent returns e's parent element, or nil if e is a top-level element.
+func (e *Element) Parent() *Element {
+	return e.parent
+}
+
+// RemoveChild removes child from e's children, if present, reporting
+// whether it was found.
+func (e *Element) RemoveChild(child *Element) bool {
+	for i, c := range e.Children {
+		if c == child {
+			e.Children = append(e.Children[:i], e.Children[i+1:]...)
+			child.parent = nil
+			return true
+		}
+	}
+	return false
+}
+
+// SelectElement returns e's first direct child with the given tag ("*"
+// matches any tag), or nil if there is none.
+func (e *Element) SelectElement(tag string) *Element {
+	for _, c := range e.Children {
+		if tagMatches(c.Tag, tag) {
+			return c
+		}
+	}
+	return nil
+}
+
+// root walks up to the top-level ancestor of e.
+func (e *Element) root() *Element {
+	n := e
+	for n.parent != nil {
+		n = n.parent
+	}
+	return n
+}
+
+// render walks the element tree through Builder's regular Element/Attr/
+// Chars/End calls, so it participates in the builder's existing
+// doIndent/empty/inline rendering logic unchanged.
+func (e *Element) render(b *Builder) {
+	b.Element(e.Tag)
+	for _, a := range e.Attrs {
+		b.Attr(a.Name, a.Value)
+	}
+	if e.Text != "" {
+		b.Chars(e.Text)
+	}
+	for _, c := range e.Children {
+		c.render(b)
+	}
+	b.End()
+}
+
+func tagMatches(tag, want string) bool {
+	return want == "*" || tag == want
+}
@@ -0,0 +1,136 @@
+package xmlbuilder
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDocumentWriteTo(t *testing.T) {
+	doc := NewDocument()
+	root := doc.CreateElement("people")
+	root.CreateAttr("id", "1")
+	person := root.CreateElement("person")
+	person.CreateAttr("id", "1")
+	person.SetText("Joran")
+	root.CreateElement("person").CreateAttr("id", "2")
+
+	buf := &bytes.Buffer{}
+	if _, err := doc.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<people id="1">
+  <person id="1">
+    Joran
+  </person>
+  <person id="2" />
+</people>
+`
+	if a, b := expected, buf.String(); a != b {
+		t.Errorf("%s and %s are not equal", a, b)
+	}
+}
+
+func buildPeopleDoc() *Document {
+	doc := NewDocument()
+	root := doc.CreateElement("people")
+	for i, name := range []string{"Joran", "Anne", "Piet"} {
+		p := root.CreateElement("person")
+		p.CreateAttr("id", string(rune('1'+i)))
+		p.SetText(name)
+	}
+	return doc
+}
+
+func TestSelectElement(t *testing.T) {
+	doc := buildPeopleDoc()
+	first := doc.SelectElement("person")
+	if first == nil || first.Text != "Joran" {
+		t.Errorf("expected first person to be Joran, got %v", first)
+	}
+	if doc.SelectElement("nope") != nil {
+		t.Errorf("expected no match for an unknown tag")
+	}
+}
+
+func TestFindElementsChildAndIndex(t *testing.T) {
+	doc := buildPeopleDoc()
+
+	all := doc.Root().FindElements("person")
+	if len(all) != 3 {
+		t.Fatalf("expected 3 persons, got %d", len(all))
+	}
+
+	second := doc.Root().FindElements("person[2]")
+	if len(second) != 1 || second[0].Text != "Anne" {
+		t.Errorf("expected person[2] to be Anne, got %v", second)
+	}
+}
+
+func TestFindElementsAttrPredicate(t *testing.T) {
+	doc := buildPeopleDoc()
+
+	match := doc.Root().FindElements(`person[@id='2']`)
+	if len(match) != 1 || match[0].Text != "Anne" {
+		t.Errorf("expected @id='2' to match Anne, got %v", match)
+	}
+
+	notMatch := doc.Root().FindElements(`person[@id!='2']`)
+	if len(notMatch) != 2 {
+		t.Errorf("expected @id!='2' to match 2 persons, got %d", len(notMatch))
+	}
+}
+
+func TestFindElementsAbsoluteDescendantAndParent(t *testing.T) {
+	doc := buildPeopleDoc()
+	third := doc.Root().FindElements("person[3]")[0]
+
+	fromRoot := third.FindElements("/people/person[1]")
+	if len(fromRoot) != 1 || fromRoot[0].Text != "Joran" {
+		t.Errorf("expected absolute path to reach Joran, got %v", fromRoot)
+	}
+
+	descendants := doc.Root().FindElements("//person")
+	if len(descendants) != 3 {
+		t.Errorf("expected 3 descendant persons, got %d", len(descendants))
+	}
+
+	parent := third.FindElements("..")
+	if len(parent) != 1 || parent[0] != doc.Root() {
+		t.Errorf("expected .. to reach the root element")
+	}
+}
+
+func TestFindElementsDescendantIndexIsPerParent(t *testing.T) {
+	doc := NewDocument()
+	root := doc.CreateElement("root")
+	parentA := root.CreateElement("parentA")
+	parentA.CreateElement("foo").SetText("a1")
+	parentB := root.CreateElement("parentB")
+	parentB.CreateElement("foo").SetText("b1")
+
+	first := root.FindElements("//foo[1]")
+	if len(first) != 2 {
+		t.Fatalf("expected one first-match per parent (2 total), got %d", len(first))
+	}
+	texts := map[string]bool{first[0].Text: true, first[1].Text: true}
+	if !texts["a1"] || !texts["b1"] {
+		t.Errorf("expected both parents' first foo child, got %v", first)
+	}
+}
+
+func TestElementRemoveChild(t *testing.T) {
+	doc := buildPeopleDoc()
+	root := doc.Root()
+	second := root.FindElements("person[2]")[0]
+
+	if !root.RemoveChild(second) {
+		t.Fatal("expected RemoveChild to find the element")
+	}
+	if len(root.Children) != 2 {
+		t.Errorf("expected 2 remaining children, got %d", len(root.Children))
+	}
+	if second.Parent() != nil {
+		t.Errorf("expected removed element to have no parent")
+	}
+}
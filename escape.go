@@ -0,0 +1,223 @@
+package xmlbuilder
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CharPolicy controls how EscapeText (and therefore every escaping builder
+// call) handles bytes that are not well-formed UTF-8 or that decode to a
+// codepoint the XML 1.0 Char production disallows (U+0000 and most of the
+// C0 control range, outside of tab, newline and carriage return).
+type CharPolicy int
+
+const (
+	// Replace substitutes U+FFFD for the offending byte or codepoint. This
+	// is the default policy.
+	Replace CharPolicy = iota
+	// Drop silently omits the offending byte or codepoint.
+	Drop
+	// Error makes EscapeText (and any builder call that escapes through
+	// it) fail, recording the error for Err to return.
+	Error
+)
+
+// OnInvalidChar sets the policy used when escaping text that contains
+// invalid UTF-8 or XML 1.0 control characters. The default is Replace.
+func (b *Builder) OnInvalidChar(policy CharPolicy) *Builder {
+	b.invalidChar = policy
+	return b
+}
+
+// Strict enables validating mode: Element, Attr and Cdata start recording
+// an error (see Err) when given a name that is not a valid XML Name, or a
+// CDATA payload containing "]]>". Strict is off by default, matching the
+// builder's historical permissive behavior.
+func (b *Builder) Strict(enabled bool) *Builder {
+	b.strict = enabled
+	return b
+}
+
+// Err returns the first error recorded by Strict-mode validation, by an
+// Error-policy EscapeText call, or by ElementNS/AttrNS for a prefix that
+// was never registered with NS, or nil if none occurred. Because Element,
+// Attr and friends are chainable and don't return errors themselves, Err is
+// how a caller using Strict or OnInvalidChar(Error) finds out something
+// went wrong.
+func (b *Builder) Err() error {
+	return b.err
+}
+
+func (b *Builder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// EscapeText writes the escaped form of s to w, the way encoding/xml would
+// for an attribute value: &, < and > become entity references, and " and ',
+// tab, newline and carriage return become numeric character references
+// (&#34;, &#39;, &#x9;, &#xA;, &#xD;) so they survive untouched. Invalid UTF-8 and XML 1.0 control characters are
+// handled according to the builder's CharPolicy (see OnInvalidChar).
+func (b *Builder) EscapeText(w io.Writer, s []byte) error {
+	return b.escapeTo(w, s, true)
+}
+
+// escapeTo is EscapeText's shared implementation. escapeNewline mirrors
+// encoding/xml's own distinction between escaping attribute values (where
+// a literal newline would be lost to whitespace normalization on reparse,
+// so it must become "&#xA;") and element text (where encoding/xml leaves a
+// literal newline as-is); tab and carriage return are always escaped
+// either way, since both are XML 1.0 whitespace that would otherwise be
+// normalized or stripped on reparse.
+func (b *Builder) escapeTo(w io.Writer, s []byte, escapeNewline bool) error {
+	last := 0
+	for i := 0; i < len(s); {
+		r, width := utf8.DecodeRune(s[i:])
+		if r == utf8.RuneError && width <= 1 {
+			if err := b.flushInvalid(w, s, &last, i, 1); err != nil {
+				return err
+			}
+			i++
+			continue
+		}
+		if !isValidXMLChar(r) {
+			if err := b.flushInvalid(w, s, &last, i, width); err != nil {
+				return err
+			}
+			i += width
+			continue
+		}
+
+		var esc string
+		switch r {
+		case '&':
+			esc = "&amp;"
+		case '<':
+			esc = "&lt;"
+		case '>':
+			esc = "&gt;"
+		case '"':
+			esc = "&#34;"
+		case '\'':
+			esc = "&#39;"
+		case '\t':
+			esc = "&#x9;"
+		case '\n':
+			if !escapeNewline {
+				i += width
+				continue
+			}
+			esc = "&#xA;"
+		case '\r':
+			esc = "&#xD;"
+		default:
+			i += width
+			continue
+		}
+		if _, err := w.Write(s[last:i]); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, esc); err != nil {
+			return err
+		}
+		i += width
+		last = i
+	}
+	_, err := w.Write(s[last:])
+	return err
+}
+
+// flushInvalid writes s[*last:at], then handles the width bytes at at
+// according to the builder's CharPolicy, advancing *last past them.
+func (b *Builder) flushInvalid(w io.Writer, s []byte, last *int, at, width int) error {
+	if _, err := w.Write(s[*last:at]); err != nil {
+		return err
+	}
+	*last = at + width
+
+	switch b.invalidChar {
+	case Drop:
+		return nil
+	case Error:
+		err := fmt.Errorf("xmlbuilder: invalid character at byte offset %d", at)
+		b.fail(err)
+		return err
+	default: // Replace
+		_, err := io.WriteString(w, "�")
+		return err
+	}
+}
+
+// isValidXMLChar reports whether r is allowed by the XML 1.0 Char
+// production: tab, newline, carriage return, or any codepoint from U+0020
+// up except surrogates, U+FFFE and U+FFFF. The C1 controls (U+0080 to
+// U+009F) are allowed.
+func isValidXMLChar(r rune) bool {
+	switch {
+	case r == '\t' || r == '\n' || r == '\r':
+		return true
+	case r >= 0x20 && r <= 0xD7FF:
+		return true
+	case r >= 0xE000 && r <= 0xFFFD:
+		return true
+	case r >= 0x10000 && r <= 0x10FFFF:
+		return true
+	default:
+		return false
+	}
+}
+
+// escapeString runs str through EscapeText (attribute-value escaping) and
+// returns the result, recording any Error-policy failure via fail instead
+// of returning it, so callers can stay on the chainable Builder API.
+func (b *Builder) escapeString(str string) string {
+	buf := &bytes.Buffer{}
+	if err := b.escapeTo(buf, []byte(str), true); err != nil {
+		b.fail(err)
+	}
+	return buf.String()
+}
+
+// escapeCharData is escapeString's element-text counterpart: like
+// escapeString, but leaves a literal newline untouched instead of turning
+// it into "&#xA;", matching encoding/xml's own element-text escaping.
+func (b *Builder) escapeCharData(str string) string {
+	buf := &bytes.Buffer{}
+	if err := b.escapeTo(buf, []byte(str), false); err != nil {
+		b.fail(err)
+	}
+	return buf.String()
+}
+
+// validateName reports an error if name is not a valid XML Name
+// production (a practical subset: NameStartChar is a letter, '_' or ':',
+// NameChar additionally allows digits, '-' and '.').
+func validateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("xmlbuilder: empty name is not a valid XML Name")
+	}
+	for i, r := range name {
+		if i == 0 {
+			if !isNameStartChar(r) {
+				return fmt.Errorf("xmlbuilder: %q is not a valid XML Name", name)
+			}
+			continue
+		}
+		if !isNameChar(r) {
+			return fmt.Errorf("xmlbuilder: %q is not a valid XML Name", name)
+		}
+	}
+	return nil
+}
+
+func isNameStartChar(r rune) bool {
+	return r == ':' || r == '_' || unicode.IsLetter(r)
+}
+
+func isNameChar(r rune) bool {
+	return isNameStartChar(r) || r == '-' || r == '.' || unicode.IsDigit(r)
+}
@@ -0,0 +1,124 @@
+package xmlbuilder
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEscapeTextDefault(t *testing.T) {
+	buf := &bytes.Buffer{}
+	b := New(buf)
+	if err := b.EscapeText(buf, []byte("a & b <c> \"d\" 'e'\tf\ng\rh")); err != nil {
+		t.Fatal(err)
+	}
+	expected := `a &amp; b &lt;c&gt; &#34;d&#34; &#39;e&#39;&#x9;f&#xA;g&#xD;h`
+	if a, c := expected, buf.String(); a != c {
+		t.Errorf("%s and %s are not equal", a, c)
+	}
+}
+
+func TestEscapeTextInvalidCharPolicies(t *testing.T) {
+	input := []byte("a\x00b")
+
+	buf := &bytes.Buffer{}
+	b := New(buf).OnInvalidChar(Drop)
+	if err := b.EscapeText(buf, input); err != nil {
+		t.Fatal(err)
+	}
+	if a, c := "ab", buf.String(); a != c {
+		t.Errorf("Drop: %s and %s are not equal", a, c)
+	}
+
+	buf = &bytes.Buffer{}
+	b = New(buf).OnInvalidChar(Replace)
+	if err := b.EscapeText(buf, input); err != nil {
+		t.Fatal(err)
+	}
+	if a, c := "a�b", buf.String(); a != c {
+		t.Errorf("Replace: %s and %s are not equal", a, c)
+	}
+
+	buf = &bytes.Buffer{}
+	b = New(buf).OnInvalidChar(Error)
+	if err := b.EscapeText(buf, input); err == nil {
+		t.Error("expected an error for a disallowed control character")
+	}
+}
+
+func TestAttrAndCharsEscaping(t *testing.T) {
+	buf := &bytes.Buffer{}
+	xml := New(buf)
+	xml.Element("a", "href", `1 & 2 "quoted"`).Chars("<tag> & 'text'").End()
+
+	expected := "<a href=\"1 &amp; 2 &#34;quoted&#34;\">\n  &lt;tag&gt; &amp; &#39;text&#39;\n</a>\n"
+	if a, c := expected, buf.String(); a != c {
+		t.Errorf("%s and %s are not equal", a, c)
+	}
+}
+
+func TestCharsLeavesNewlineLiteralUnlikeAttr(t *testing.T) {
+	buf := &bytes.Buffer{}
+	xml := New(buf)
+	xml.Inline().Chars("line1\nline2").EndInline()
+
+	expected := "line1\nline2\n"
+	if a, c := expected, buf.String(); a != c {
+		t.Errorf("%s and %s are not equal", a, c)
+	}
+
+	buf = &bytes.Buffer{}
+	xml = New(buf)
+	xml.Element("a", "n", "line1\nline2").End()
+
+	expected = "<a n=\"line1&#xA;line2\" />\n"
+	if a, c := expected, buf.String(); a != c {
+		t.Errorf("%s and %s are not equal", a, c)
+	}
+}
+
+func TestStrictInvalidElementName(t *testing.T) {
+	buf := &bytes.Buffer{}
+	xml := New(buf).Strict(true)
+	xml.Element("1bad").End()
+	if xml.Err() == nil {
+		t.Error("expected Err to report the invalid element name")
+	}
+}
+
+func TestStrictInvalidAttrName(t *testing.T) {
+	buf := &bytes.Buffer{}
+	xml := New(buf).Strict(true)
+	xml.Element("ok").Attr("bad name", "v").End()
+	if xml.Err() == nil {
+		t.Error("expected Err to report the invalid attribute name")
+	}
+}
+
+func TestStrictValidNamesNoError(t *testing.T) {
+	buf := &bytes.Buffer{}
+	xml := New(buf).Strict(true)
+	xml.Element("person").Attr("first-name", "Joran").End()
+	if err := xml.Err(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestStrictCdataEndToken(t *testing.T) {
+	buf := &bytes.Buffer{}
+	xml := New(buf).Strict(true)
+	xml.Element("a")
+	xml.Cdata("contains ]]> end token")
+	xml.End()
+	if xml.Err() == nil {
+		t.Error("expected Err to report the CDATA end token")
+	}
+}
+
+func TestNonStrictIsPermissive(t *testing.T) {
+	buf := &bytes.Buffer{}
+	xml := New(buf)
+	xml.Element("1bad").Attr("bad name", "v").End()
+	if err := xml.Err(); err != nil {
+		t.Errorf("expected no error outside Strict mode, got %v", err)
+	}
+}
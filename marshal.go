@@ -0,0 +1,442 @@
+package xmlbuilder
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/xml"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Marshal walks v with reflection, using the same struct tag conventions as
+// encoding/xml (`xml:"name,attr"`, `,chardata`, `,cdata`, `,comment`,
+// `,innerxml`, `,omitempty` and `>child>grandchild` paths, plus anonymous
+// field flattening), and emits the result through the builder's
+// Element/Attr/Chars/Cdata calls. Because it goes through the regular
+// builder calls, the marshalled output participates in the current
+// indent/inline/offset state, so it can be mixed freely with hand-written
+// builder code.
+func (b *Builder) Marshal(v interface{}) error {
+	if err := b.marshalValue(reflect.ValueOf(v), ""); err != nil {
+		return err
+	}
+	return b.Err()
+}
+
+// MarshalWithName is like Marshal but overrides the element name that would
+// otherwise be taken from an XMLName field or the value's type name.
+func (b *Builder) MarshalWithName(name string, v interface{}) error {
+	if err := b.marshalValue(reflect.ValueOf(v), name); err != nil {
+		return err
+	}
+	return b.Err()
+}
+
+// fieldTag holds the parsed form of a struct field's `xml` tag.
+type fieldTag struct {
+	path      []string // path[:len-1] are wrapper elements, path[len-1] is the leaf name
+	attr      bool
+	chardata  bool
+	cdata     bool
+	comment   bool
+	innerxml  bool
+	omitempty bool
+	skip      bool
+}
+
+func parseFieldTag(field reflect.StructField) fieldTag {
+	tag := field.Tag.Get("xml")
+	if tag == "-" {
+		return fieldTag{skip: true}
+	}
+
+	parts := strings.Split(tag, ",")
+	ft := fieldTag{}
+	for _, opt := range parts[1:] {
+		switch opt {
+		case "attr":
+			ft.attr = true
+		case "chardata":
+			ft.chardata = true
+		case "cdata":
+			ft.cdata = true
+		case "comment":
+			ft.comment = true
+		case "innerxml":
+			ft.innerxml = true
+		case "omitempty":
+			ft.omitempty = true
+		}
+	}
+
+	if parts[0] != "" {
+		ft.path = strings.Split(parts[0], ">")
+	} else {
+		ft.path = []string{field.Name}
+	}
+	if ft.path[len(ft.path)-1] == "" {
+		ft.path[len(ft.path)-1] = field.Name
+	}
+	return ft
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// isAnonymousEmbed reports whether field should be flattened into its
+// parent rather than treated as a regular named field: it is an anonymous
+// struct (or pointer to struct) with no xml tag at all; an embedded struct
+// carrying any xml tag, even one with only options such as ",omitempty",
+// is treated as a regular named field.
+func isAnonymousEmbed(field reflect.StructField) bool {
+	if !field.Anonymous || field.Tag.Get("xml") != "" {
+		return false
+	}
+	t := field.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+func embeddedStruct(fv reflect.Value) (reflect.Value, bool) {
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return reflect.Value{}, false
+		}
+		fv = fv.Elem()
+	}
+	return fv, fv.Kind() == reflect.Struct
+}
+
+// valueToString renders fv the way a chardata/attr/cdata field is rendered:
+// via encoding.TextMarshaler when implemented, otherwise via fmt.Sprint.
+func valueToString(fv reflect.Value) (string, error) {
+	if fv.CanInterface() {
+		if tm, ok := fv.Interface().(encoding.TextMarshaler); ok {
+			data, err := tm.MarshalText()
+			if err != nil {
+				return "", err
+			}
+			return string(data), nil
+		}
+	}
+	if fv.CanAddr() && fv.Addr().CanInterface() {
+		if tm, ok := fv.Addr().Interface().(encoding.TextMarshaler); ok {
+			data, err := tm.MarshalText()
+			if err != nil {
+				return "", err
+			}
+			return string(data), nil
+		}
+	}
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return "", nil
+		}
+		return valueToString(fv.Elem())
+	}
+	return s(fv.Interface()), nil
+}
+
+func (b *Builder) marshalAttrs(val reflect.Value) error {
+	t := val.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		if field.Name == "XMLName" {
+			continue
+		}
+		fv := val.Field(i)
+		if isAnonymousEmbed(field) {
+			if ev, ok := embeddedStruct(fv); ok {
+				if err := b.marshalAttrs(ev); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		ft := parseFieldTag(field)
+		if ft.skip || !ft.attr {
+			continue
+		}
+		if ft.omitempty && isEmptyValue(fv) {
+			continue
+		}
+		str, err := valueToString(fv)
+		if err != nil {
+			return err
+		}
+		b.Attr(ft.path[len(ft.path)-1], str)
+	}
+	return nil
+}
+
+func (b *Builder) marshalChildren(val reflect.Value, openPath *[]string) error {
+	t := val.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		if field.Name == "XMLName" {
+			continue
+		}
+		fv := val.Field(i)
+		if isAnonymousEmbed(field) {
+			if ev, ok := embeddedStruct(fv); ok {
+				if err := b.marshalChildren(ev, openPath); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		ft := parseFieldTag(field)
+		if ft.skip || ft.attr {
+			continue
+		}
+		if ft.omitempty && isEmptyValue(fv) {
+			continue
+		}
+
+		switch {
+		case ft.chardata:
+			str, err := valueToString(fv)
+			if err != nil {
+				return err
+			}
+			b.Chars(str)
+		case ft.cdata:
+			str, err := valueToString(fv)
+			if err != nil {
+				return err
+			}
+			b.Cdata(str)
+		case ft.comment:
+			str, err := valueToString(fv)
+			if err != nil {
+				return err
+			}
+			b.Comment(str)
+		case ft.innerxml:
+			str, err := valueToString(fv)
+			if err != nil {
+				return err
+			}
+			b.CharsNoEscape(str)
+		default:
+			b.openPathTo(openPath, ft.path[:len(ft.path)-1])
+			if err := b.marshalField(ft.path[len(ft.path)-1], fv); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// openPathTo adjusts the currently open wrapper elements (introduced by
+// `>child>grandchild` tags) so that want is open, reusing any shared prefix
+// with the path that is already open.
+func (b *Builder) openPathTo(openPath *[]string, want []string) {
+	common := 0
+	for common < len(*openPath) && common < len(want) && (*openPath)[common] == want[common] {
+		common++
+	}
+	for len(*openPath) > common {
+		b.End()
+		*openPath = (*openPath)[:len(*openPath)-1]
+	}
+	for _, name := range want[common:] {
+		b.Element(name)
+		*openPath = append(*openPath, name)
+	}
+}
+
+func (b *Builder) marshalField(name string, fv reflect.Value) error {
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+
+	if fv.CanInterface() {
+		if m, ok := fv.Interface().(xml.Marshaler); ok {
+			return b.marshalXMLMarshaler(name, m)
+		}
+	}
+	if fv.CanAddr() && fv.Addr().CanInterface() {
+		if m, ok := fv.Addr().Interface().(xml.Marshaler); ok {
+			return b.marshalXMLMarshaler(name, m)
+		}
+	}
+
+	switch fv.Kind() {
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.Uint8 {
+			return b.marshalScalar(name, fv)
+		}
+		for i := 0; i < fv.Len(); i++ {
+			if err := b.marshalField(name, fv.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			if err := b.marshalField(name, fv.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		return b.marshalMap(name, fv)
+	case reflect.Struct:
+		return b.marshalStruct(name, fv)
+	default:
+		return b.marshalScalar(name, fv)
+	}
+}
+
+func (b *Builder) marshalMap(name string, fv reflect.Value) error {
+	keys := fv.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return s(keys[i].Interface()) < s(keys[j].Interface())
+	})
+	for _, k := range keys {
+		keyName, err := valueToString(k)
+		if err != nil {
+			return err
+		}
+		if keyName == "" {
+			keyName = name
+		}
+		if err := b.marshalField(keyName, fv.MapIndex(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Builder) marshalStruct(name string, val reflect.Value) error {
+	b.Element(name)
+	if err := b.marshalAttrs(val); err != nil {
+		return err
+	}
+	openPath := []string{}
+	if err := b.marshalChildren(val, &openPath); err != nil {
+		return err
+	}
+	for len(openPath) > 0 {
+		b.End()
+		openPath = openPath[:len(openPath)-1]
+	}
+	b.End()
+	return nil
+}
+
+func (b *Builder) marshalScalar(name string, fv reflect.Value) error {
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Uint8 {
+		b.Tag(name, base64.StdEncoding.EncodeToString(fv.Bytes()))
+		return nil
+	}
+	str, err := valueToString(fv)
+	if err != nil {
+		return err
+	}
+	b.Tag(name, str)
+	return nil
+}
+
+// marshalXMLMarshaler delegates to v's own MarshalXML and replays the
+// resulting tokens through the builder via CopyTokens.
+func (b *Builder) marshalXMLMarshaler(name string, v xml.Marshaler) error {
+	buf := &bytes.Buffer{}
+	enc := xml.NewEncoder(buf)
+	if err := v.MarshalXML(enc, xml.StartElement{Name: xml.Name{Local: name}}); err != nil {
+		return err
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	return b.CopyTokens(xml.NewDecoder(bytes.NewReader(buf.Bytes())))
+}
+
+func xmlNameOf(v reflect.Value) string {
+	t := v.Type()
+	if t.Kind() == reflect.Struct {
+		if f, ok := t.FieldByName("XMLName"); ok && f.Type == reflect.TypeOf(xml.Name{}) {
+			if name := v.FieldByIndex(f.Index).Interface().(xml.Name).Local; name != "" {
+				return name
+			}
+		}
+	}
+	return t.Name()
+}
+
+func (b *Builder) marshalValue(v reflect.Value, name string) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+
+	if v.CanInterface() {
+		if m, ok := v.Interface().(xml.Marshaler); ok {
+			if name == "" {
+				name = xmlNameOf(v)
+			}
+			return b.marshalXMLMarshaler(name, m)
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if name == "" {
+			name = xmlNameOf(v)
+		}
+		return b.marshalStruct(name, v)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := b.marshalValue(v.Index(i), name); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		if name == "" {
+			name = v.Type().Name()
+		}
+		return b.marshalMap(name, v)
+	default:
+		if name == "" {
+			name = v.Type().Name()
+		}
+		return b.marshalScalar(name, v)
+	}
+}
@@ -0,0 +1,179 @@
+package xmlbuilder
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+type marshalPhone struct {
+	Type string `xml:"type,attr"`
+	Nr   string `xml:",chardata"`
+}
+
+type marshalPerson struct {
+	Name    string         `xml:"name,attr"`
+	Age     int            `xml:"age,attr,omitempty"`
+	Phones  []marshalPhone `xml:"phone"`
+	Address struct {
+		Street string `xml:"street"`
+		City   string `xml:"city"`
+	} `xml:"address>home"`
+}
+
+func TestMarshalStruct(t *testing.T) {
+	p := marshalPerson{Name: "Joran", Age: 40}
+	p.Phones = []marshalPhone{{Type: "mobile", Nr: "1298376142"}}
+	p.Address.Street = "Some street"
+	p.Address.City = "Eindhoven"
+
+	buf := &bytes.Buffer{}
+	xml := New(buf)
+	if err := xml.Marshal(p); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<marshalPerson name="Joran" age="40">
+  <phone type="mobile">
+    1298376142
+  </phone>
+  <address>
+    <home>
+      <street>Some street</street>
+      <city>Eindhoven</city>
+    </home>
+  </address>
+</marshalPerson>
+`
+	if a, b := expected, buf.String(); a != b {
+		t.Errorf("%s and %s are not equal", a, b)
+	}
+}
+
+func TestMarshalWithName(t *testing.T) {
+	type item struct {
+		Name string `xml:",chardata"`
+	}
+
+	buf := &bytes.Buffer{}
+	xml := New(buf)
+	if err := xml.MarshalWithName("fruit", item{Name: "apple"}); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := "<fruit>\n  apple\n</fruit>\n", buf.String(); a != b {
+		t.Errorf("%s and %s are not equal", a, b)
+	}
+}
+
+func TestMarshalOmitemptyAndPointer(t *testing.T) {
+	type inner struct {
+		Value string `xml:"value,omitempty"`
+	}
+	type withPtr struct {
+		Inner *inner `xml:"inner"`
+	}
+
+	buf := &bytes.Buffer{}
+	xml := New(buf)
+	if err := xml.Marshal(withPtr{}); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := "<withPtr />\n", buf.String(); a != b {
+		t.Errorf("%s and %s are not equal", a, b)
+	}
+
+	buf = &bytes.Buffer{}
+	xml = New(buf)
+	if err := xml.Marshal(withPtr{Inner: &inner{}}); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := "<withPtr>\n  <inner />\n</withPtr>\n", buf.String(); a != b {
+		t.Errorf("%s and %s are not equal", a, b)
+	}
+}
+
+func TestMarshalSlice(t *testing.T) {
+	type fruit struct {
+		Name string `xml:",chardata"`
+	}
+	type basket struct {
+		Fruits []fruit `xml:"fruit"`
+	}
+
+	buf := &bytes.Buffer{}
+	xml := New(buf)
+	if err := xml.Marshal(basket{Fruits: []fruit{{Name: "apple"}, {Name: "pear"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<basket>
+  <fruit>
+    apple
+  </fruit>
+  <fruit>
+    pear
+  </fruit>
+</basket>
+`
+	if a, b := expected, buf.String(); a != b {
+		t.Errorf("%s and %s are not equal", a, b)
+	}
+}
+
+type marshalTextValue struct {
+	val string
+}
+
+func (v marshalTextValue) MarshalText() ([]byte, error) {
+	return []byte("text:" + v.val), nil
+}
+
+func TestMarshalTextMarshaler(t *testing.T) {
+	type holder struct {
+		Value marshalTextValue `xml:",chardata"`
+	}
+
+	buf := &bytes.Buffer{}
+	xml := New(buf)
+	if err := xml.Marshal(holder{Value: marshalTextValue{val: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := "<holder>\n  text:hi\n</holder>\n", buf.String(); a != b {
+		t.Errorf("%s and %s are not equal", a, b)
+	}
+}
+
+type marshalTextPtrValue struct {
+	n int
+}
+
+func (v *marshalTextPtrValue) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("n=%d", v.n)), nil
+}
+
+func TestMarshalTextMarshalerPointerReceiver(t *testing.T) {
+	type holder struct {
+		Value marshalTextPtrValue `xml:",chardata"`
+	}
+
+	buf := &bytes.Buffer{}
+	xml := New(buf)
+	if err := xml.Marshal(&holder{Value: marshalTextPtrValue{n: 5}}); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := "<holder>\n  n=5\n</holder>\n", buf.String(); a != b {
+		t.Errorf("%s and %s are not equal", a, b)
+	}
+}
+
+func TestMarshalStrictReportsInvalidName(t *testing.T) {
+	type badAttr struct {
+		Value string `xml:"bad name,attr"`
+	}
+
+	buf := &bytes.Buffer{}
+	xml := New(buf).Strict(true)
+	if err := xml.Marshal(badAttr{Value: "v"}); err == nil {
+		t.Error("expected Marshal to report the invalid attribute name recorded by Strict mode")
+	}
+}
@@ -0,0 +1,247 @@
+package xmlbuilder
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// NS registers a namespace prefix so it can later be used with ElementNS,
+// AttrNS, or through an xml.Name{Space: uri} value passed to Element/Attr.
+// Registering a prefix does not by itself emit anything; the matching
+// xmlns(:prefix) declaration is written lazily, on the first element that
+// actually uses it (or on the root element when NSRoot(true) was called).
+//
+// When called while an element is open, the registration is scoped to that
+// element: once it is closed with End, prefix reverts to whatever it
+// resolved to in the parent scope (or becomes unregistered again, if it
+// wasn't registered there either), so a sibling built afterwards is
+// unaffected by a rebinding done for one child.
+func (b *Builder) NS(prefix, uri string) *Builder {
+	if b.namespaces == nil {
+		b.namespaces = make(map[string]string)
+	}
+	if n := len(b.elements); n > 0 {
+		old, had := b.namespaces[prefix]
+		b.elements[n-1].nsRestore = append(b.elements[n-1].nsRestore, nsRestore{prefix: prefix, hadValue: had, value: old})
+	}
+	b.namespaces[prefix] = uri
+	return b
+}
+
+// restoreNamespaces undoes, in reverse order, every NS() catalog overwrite
+// that was scoped to frame, as recorded by NS while frame was the innermost
+// open element.
+func (b *Builder) restoreNamespaces(frame elementFrame) {
+	for i := len(frame.nsRestore) - 1; i >= 0; i-- {
+		r := frame.nsRestore[i]
+		if r.hadValue {
+			b.namespaces[r.prefix] = r.value
+		} else {
+			delete(b.namespaces, r.prefix)
+		}
+	}
+}
+
+// NSRoot controls where namespace declarations for registered prefixes are
+// placed. By default (false) each xmlns(:prefix) is declared lazily on the
+// first element that introduces it. When true, every prefix registered with
+// NS is declared on the root element instead.
+func (b *Builder) NSRoot(atRoot bool) *Builder {
+	b.nsAtRoot = atRoot
+	return b
+}
+
+// ElementNS is like Element but qualifies the element with the namespace
+// prefix registered for prefix, declaring it on this element if it is not
+// already in scope. If prefix was never registered with NS, the element is
+// still written (with an empty xmlns:prefix declaration rather than none at
+// all) but the missing registration is recorded as an error, retrievable
+// through Err.
+func (b *Builder) ElementNS(prefix, name string, args ...interface{}) *Builder {
+	if b.buildingElement {
+		b.outputElement(false)
+	}
+
+	b.buildingElement = true
+	b.openElement(prefix, name, true)
+	first := len(args) % 2
+	for i := first; i < len(args); i += 2 {
+		if a, v := s(args[i+0]), s(args[i+1]); a != "" && (v != "" || args[i+1] == Blank) {
+			b.Attr(a, v)
+		}
+	}
+	if first != 0 {
+		b.Chars(args[0])
+	}
+	return b
+}
+
+// AttrNS is like Attr but qualifies the attribute with the namespace prefix
+// registered for prefix, declaring it on the current element if it is not
+// already in scope. As with ElementNS, an unregistered prefix is recorded
+// as an error (see Err) rather than silently omitting the declaration.
+func (b *Builder) AttrNS(prefix, name string, value interface{}) *Builder {
+	b.declareOnCurrent(prefix)
+	return b.Attr(prefix+":"+name, value)
+}
+
+// currentBound returns the namespace prefixes visible from inside the
+// element currently being built (or nil at the root scope).
+func (b *Builder) currentBound() map[string]string {
+	if n := len(b.elements); n > 0 {
+		return b.elements[n-1].bound
+	}
+	return nil
+}
+
+func cloneBound(bound map[string]string) map[string]string {
+	clone := make(map[string]string, len(bound)+1)
+	for k, v := range bound {
+		clone[k] = v
+	}
+	return clone
+}
+
+// openElement resolves the tag name for a new element and pushes its frame,
+// declaring prefix (and, on the root element when NSRoot(true) is set,
+// every namespace registered through NS) that is not already in scope.
+func (b *Builder) openElement(prefix, name string, useNS bool) {
+	parentBound := b.currentBound()
+	newBound := parentBound
+	cloned := false
+
+	declare := func(p string) {
+		uri, registered := b.namespaces[p]
+		if !registered {
+			b.fail(fmt.Errorf("xmlbuilder: namespace prefix %q was not registered with NS", p))
+		}
+		if bound, ok := newBound[p]; ok && bound == uri {
+			return
+		}
+		if !cloned {
+			newBound = cloneBound(parentBound)
+			cloned = true
+		}
+		newBound[p] = uri
+
+		attrName := "xmlns"
+		if p != "" {
+			attrName = "xmlns:" + p
+		}
+		b.Attr(attrName, uri)
+	}
+
+	if len(b.elements) == 0 && b.nsAtRoot {
+		for p := range b.namespaces {
+			declare(p)
+		}
+	}
+	if useNS {
+		declare(prefix)
+	}
+
+	qualified := name
+	if useNS && prefix != "" {
+		qualified = prefix + ":" + name
+	}
+	if b.strict {
+		if err := validateName(qualified); err != nil {
+			b.fail(err)
+		}
+	}
+	b.elements = append(b.elements, elementFrame{name: qualified, bound: newBound})
+}
+
+// declareOnCurrent declares prefix, bound to its registered URI, on the
+// element currently being built (or queued for the next element, when none
+// is open yet) if it is not already in scope. If prefix was never
+// registered with NS, the declaration is still written (with an empty URI)
+// rather than silently dropped, and the missing registration is recorded as
+// an error, retrievable through Err.
+func (b *Builder) declareOnCurrent(prefix string) {
+	uri, registered := b.namespaces[prefix]
+	if !registered {
+		b.fail(fmt.Errorf("xmlbuilder: namespace prefix %q was not registered with NS", prefix))
+	}
+	bound := b.currentBound()
+	if existing, ok := bound[prefix]; ok && existing == uri {
+		return
+	}
+
+	attrName := "xmlns"
+	if prefix != "" {
+		attrName = "xmlns:" + prefix
+	}
+	b.Attr(attrName, uri)
+
+	if n := len(b.elements); n > 0 {
+		next := cloneBound(bound)
+		next[prefix] = uri
+		b.elements[n-1].bound = next
+	}
+}
+
+// pushElement opens a new element frame that inherits the namespace
+// bindings currently in scope, without declaring any of its own.
+func (b *Builder) pushElement(name string) {
+	b.openElement("", name, false)
+}
+
+// prefixForURI returns the prefix registered for uri, if any, preferring a
+// non-default (non-empty) prefix.
+func (b *Builder) prefixForURI(uri string) (string, bool) {
+	for prefix, u := range b.namespaces {
+		if u == uri && prefix != "" {
+			return prefix, true
+		}
+	}
+	for prefix, u := range b.namespaces {
+		if u == uri {
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+// resolveName turns an element argument accepted by Element/ElementNoEscape
+// into the tag name to emit, pushing the element's frame along the way. A
+// plain string is pushed unqualified; an xml.Name{Space, Local} is
+// qualified with the prefix registered for Space (declaring it if needed),
+// or, when no such prefix is registered, bound as a fresh default namespace.
+func (b *Builder) resolveName(v interface{}) {
+	name, ok := v.(xml.Name)
+	if !ok {
+		b.openElement("", s(v), false)
+		return
+	}
+	if name.Space == "" {
+		b.openElement("", name.Local, false)
+		return
+	}
+	if prefix, ok := b.prefixForURI(name.Space); ok {
+		b.openElement(prefix, name.Local, true)
+		return
+	}
+	b.NS("", name.Space)
+	b.openElement("", name.Local, true)
+}
+
+// resolveAttrName turns an attribute name argument accepted by Attr into the
+// attribute name to emit, qualifying xml.Name{Space, Local} values the same
+// way resolveName does for elements. An unprefixed (default namespace)
+// attribute is not meaningful in XML, so a Space with no registered prefix
+// is rendered using its bare Local name.
+func (b *Builder) resolveAttrName(v interface{}) string {
+	name, ok := v.(xml.Name)
+	if !ok {
+		return s(v)
+	}
+	if name.Space == "" {
+		return name.Local
+	}
+	if prefix, ok := b.prefixForURI(name.Space); ok && prefix != "" {
+		b.declareOnCurrent(prefix)
+		return prefix + ":" + name.Local
+	}
+	return name.Local
+}
@@ -0,0 +1,171 @@
+package xmlbuilder
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+)
+
+func TestElementNS(t *testing.T) {
+	buf := &bytes.Buffer{}
+	xml := New(buf)
+	xml.NS("D", "DAV:")
+	xml.ElementNS("D", "propfind")
+	{
+		xml.ElementNS("D", "allprop").End()
+	}
+	xml.End()
+
+	expected := `<D:propfind xmlns:D="DAV:">
+  <D:allprop />
+</D:propfind>
+`
+	if a, b := expected, buf.String(); a != b {
+		t.Errorf("%s and %s are not equal", a, b)
+	}
+}
+
+func TestElementNSDeclaresOnlyOnce(t *testing.T) {
+	buf := &bytes.Buffer{}
+	xml := New(buf)
+	xml.NS("D", "DAV:")
+	xml.ElementNS("D", "multistatus")
+	{
+		xml.ElementNS("D", "response")
+		{
+			xml.AttrNS("D", "href", "/")
+		}
+		xml.End()
+	}
+	xml.End()
+
+	expected := `<D:multistatus xmlns:D="DAV:">
+  <D:response D:href="/" />
+</D:multistatus>
+`
+	if a, b := expected, buf.String(); a != b {
+		t.Errorf("%s and %s are not equal", a, b)
+	}
+}
+
+func TestElementNSRebinding(t *testing.T) {
+	buf := &bytes.Buffer{}
+	xml := New(buf)
+	xml.NS("a", "urn:a")
+	xml.ElementNS("a", "root")
+	{
+		xml.NS("a", "urn:b")
+		xml.ElementNS("a", "child").End()
+	}
+	xml.End()
+
+	expected := `<a:root xmlns:a="urn:a">
+  <a:child xmlns:a="urn:b" />
+</a:root>
+`
+	if a, b := expected, buf.String(); a != b {
+		t.Errorf("%s and %s are not equal", a, b)
+	}
+}
+
+func TestElementNSRebindingDoesNotLeakToSibling(t *testing.T) {
+	buf := &bytes.Buffer{}
+	xml := New(buf)
+	xml.NS("a", "urn:a")
+	xml.ElementNS("a", "root")
+	{
+		xml.ElementNS("a", "child1")
+		{
+			xml.NS("a", "urn:b")
+			xml.ElementNS("a", "grandchild").End()
+		}
+		xml.End()
+		xml.ElementNS("a", "child2").End()
+	}
+	xml.End()
+
+	expected := `<a:root xmlns:a="urn:a">
+  <a:child1>
+    <a:grandchild xmlns:a="urn:b" />
+  </a:child1>
+  <a:child2 />
+</a:root>
+`
+	if a, b := expected, buf.String(); a != b {
+		t.Errorf("%s and %s are not equal", a, b)
+	}
+}
+
+func TestNSRoot(t *testing.T) {
+	buf := &bytes.Buffer{}
+	xml := New(buf)
+	xml.NS("D", "DAV:")
+	xml.NSRoot(true)
+	xml.ElementNS("D", "propfind")
+	{
+		xml.ElementNS("D", "allprop").End()
+	}
+	xml.End()
+
+	expected := `<D:propfind xmlns:D="DAV:">
+  <D:allprop />
+</D:propfind>
+`
+	if a, b := expected, buf.String(); a != b {
+		t.Errorf("%s and %s are not equal", a, b)
+	}
+}
+
+func TestElementWithXMLName(t *testing.T) {
+	buf := &bytes.Buffer{}
+	xml := New(buf)
+	xml.NS("D", "DAV:")
+	xml.Element(xmlbuilderName("DAV:", "propfind"))
+	{
+		xml.Element(xmlbuilderName("DAV:", "allprop")).End()
+	}
+	xml.End()
+
+	expected := `<D:propfind xmlns:D="DAV:">
+  <D:allprop />
+</D:propfind>
+`
+	if a, b := expected, buf.String(); a != b {
+		t.Errorf("%s and %s are not equal", a, b)
+	}
+}
+
+func xmlbuilderName(space, local string) xml.Name {
+	return xml.Name{Space: space, Local: local}
+}
+
+func TestElementNSUnregisteredPrefixStillDeclares(t *testing.T) {
+	buf := &bytes.Buffer{}
+	xml := New(buf)
+	xml.ElementNS("D", "propfind").End()
+
+	expected := "<D:propfind xmlns:D=\"\" />\n"
+	if a, b := expected, buf.String(); a != b {
+		t.Errorf("%s and %s are not equal", a, b)
+	}
+	if xml.Err() == nil {
+		t.Error("expected Err to report the unregistered prefix")
+	}
+}
+
+func TestAttrNSUnregisteredPrefixStillDeclares(t *testing.T) {
+	buf := &bytes.Buffer{}
+	xml := New(buf)
+	xml.Element("response")
+	xml.AttrNS("D", "href", "/")
+	xml.End()
+
+	expected := `<response xmlns:D="" D:href="/" />
+`
+	if a, b := expected, buf.String(); a != b {
+		t.Errorf("%s and %s are not equal", a, b)
+	}
+	if xml.Err() == nil {
+		t.Error("expected Err to report the unregistered prefix")
+	}
+}
@@ -0,0 +1,113 @@
+package xmlbuilder
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Comment adds an XML comment to the document, respecting the current
+// indent/inline state. text is written verbatim between <!-- and -->; the
+// caller is responsible for ensuring it does not itself contain "--".
+func (b *Builder) Comment(text string) *Builder {
+	return b.writeRaw("<!--", text, "-->")
+}
+
+// ProcInst adds a processing instruction, e.g. ProcInst("xml-stylesheet",
+// `type="text/xsl" href="style.xsl"`) for <?xml-stylesheet type="text/xsl"
+// href="style.xsl"?>. Unlike Instruct, which builds its instruction from
+// name/value attribute pairs, ProcInst takes the instruction body as-is.
+func (b *Builder) ProcInst(target, inst string) *Builder {
+	body := target
+	if inst != "" {
+		body += " " + inst
+	}
+	return b.writeRaw("<?", body, "?>")
+}
+
+// Directive adds a raw XML directive such as <!DOCTYPE html> or
+// <!ENTITY ...>, respecting the current indent/inline state. text is the
+// content between <! and >, without surrounding whitespace added.
+func (b *Builder) Directive(text string) *Builder {
+	return b.writeRaw("<!", text, ">")
+}
+
+// writeRaw flushes any element currently being built and writes
+// prefix+text+suffix as a single document-level token, indented like a
+// sibling element when inside one, inline when inline mode is active, and
+// unindented at the very top of the document (matching Doctype/Instruct).
+func (b *Builder) writeRaw(prefix, text, suffix string) *Builder {
+	b.outputElement(false)
+	switch {
+	case b.inline > 0:
+		fmt.Fprint(b.writer, prefix, text, suffix)
+	case len(b.elements) > 0:
+		fmt.Fprint(b.writer, b.doIndent(), b.indent, prefix, text, suffix, "\n")
+	default:
+		fmt.Fprint(b.writer, prefix, text, suffix, "\n")
+	}
+	return b
+}
+
+// WriteToken dispatches an encoding/xml token to the matching builder call
+// (Element/Attr, End, Chars, Comment, ProcInst or Directive), so a Builder
+// can consume the output of an xml.Decoder directly. CharData tokens that
+// are pure indentation/newline whitespace (as found between sibling tags in
+// an already pretty-printed source document) are trimmed and skipped rather
+// than replayed as spurious text nodes; all other CharData is passed through
+// trimmed of its surrounding whitespace. Token types outside the standard
+// set are ignored.
+func (b *Builder) WriteToken(tok xml.Token) *Builder {
+	switch t := tok.(type) {
+	case xml.StartElement:
+		b.Element(t.Name)
+		for _, a := range t.Attr {
+			if isNSDecl(a.Name) {
+				// xmlns/xmlns:prefix declarations are reconstructed by
+				// Element/Attr's own namespace handling; replaying them
+				// verbatim would emit them as bogus plain attributes.
+				continue
+			}
+			b.Attr(a.Name, a.Value)
+		}
+	case xml.EndElement:
+		b.End()
+	case xml.CharData:
+		if text := strings.TrimSpace(string(t)); text != "" {
+			b.Chars(text)
+		}
+	case xml.Comment:
+		b.Comment(string(t))
+	case xml.ProcInst:
+		b.ProcInst(t.Target, string(t.Inst))
+	case xml.Directive:
+		b.Directive(string(t))
+	}
+	return b
+}
+
+// isNSDecl reports whether name is the name of a namespace declaration
+// attribute as produced by xml.Decoder: xmlns="..." decodes to
+// {Space: "", Local: "xmlns"}, xmlns:prefix="..." to {Space: "xmlns",
+// Local: prefix}.
+func isNSDecl(name xml.Name) bool {
+	return name.Space == "xmlns" || (name.Space == "" && name.Local == "xmlns")
+}
+
+// CopyTokens reads every token from dec and writes it through WriteToken,
+// letting an existing document be piped through a Builder to reformat it or
+// splice in additional elements. It returns nil at io.EOF and any other
+// decoding error as-is.
+func (b *Builder) CopyTokens(dec *xml.Decoder) error {
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		b.WriteToken(tok)
+	}
+}
@@ -0,0 +1,114 @@
+package xmlbuilder
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+)
+
+func TestComment(t *testing.T) {
+	buf := &bytes.Buffer{}
+	b := New(buf)
+	b.Element("people")
+	{
+		b.Comment(" people go here ")
+	}
+	b.End()
+
+	expected := `<people>
+  <!-- people go here -->
+</people>
+`
+	if a, c := expected, buf.String(); a != c {
+		t.Errorf("%s and %s are not equal", a, c)
+	}
+}
+
+func TestProcInst(t *testing.T) {
+	buf := &bytes.Buffer{}
+	b := New(buf)
+	b.ProcInst("xml-stylesheet", `type="text/xsl" href="style.xsl"`)
+	b.Tag("root")
+
+	expected := `<?xml-stylesheet type="text/xsl" href="style.xsl"?>
+<root />
+`
+	if a, c := expected, buf.String(); a != c {
+		t.Errorf("%s and %s are not equal", a, c)
+	}
+}
+
+func TestDirective(t *testing.T) {
+	buf := &bytes.Buffer{}
+	b := New(buf)
+	b.Directive("DOCTYPE html")
+	b.Tag("html")
+
+	expected := "<!DOCTYPE html>\n<html />\n"
+	if a, c := expected, buf.String(); a != c {
+		t.Errorf("%s and %s are not equal", a, c)
+	}
+}
+
+func TestWriteTokenAndCopyTokens(t *testing.T) {
+	src := `<people><person id="1">Joran</person><!--a comment--></people>`
+	dec := xml.NewDecoder(bytes.NewReader([]byte(src)))
+
+	buf := &bytes.Buffer{}
+	b := New(buf)
+	if err := b.CopyTokens(dec); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<people>
+  <person id="1">
+    Joran
+  </person>
+  <!--a comment-->
+</people>
+`
+	if a, c := expected, buf.String(); a != c {
+		t.Errorf("%s and %s are not equal", a, c)
+	}
+}
+
+func TestCopyTokensSkipsFormattingWhitespace(t *testing.T) {
+	src := "<root>\n  <a>hello</a>\n  <b/>\n</root>"
+	dec := xml.NewDecoder(bytes.NewReader([]byte(src)))
+
+	buf := &bytes.Buffer{}
+	b := New(buf)
+	if err := b.CopyTokens(dec); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<root>
+  <a>
+    hello
+  </a>
+  <b />
+</root>
+`
+	if a, c := expected, buf.String(); a != c {
+		t.Errorf("%s and %s are not equal", a, c)
+	}
+}
+
+func TestCopyTokensPreservesNamespace(t *testing.T) {
+	src := `<D:multistatus xmlns:D="DAV:"><D:response D:href="/"/></D:multistatus>`
+	dec := xml.NewDecoder(bytes.NewReader([]byte(src)))
+
+	buf := &bytes.Buffer{}
+	b := New(buf)
+	if err := b.CopyTokens(dec); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<multistatus xmlns="DAV:">
+  <response href="/" />
+</multistatus>
+`
+	if a, c := expected, buf.String(); a != c {
+		t.Errorf("%s and %s are not equal", a, c)
+	}
+}
@@ -0,0 +1,214 @@
+package xmlbuilder
+
+import (
+	"strconv"
+	"strings"
+)
+
+// pathSegment is one step of a parsed XPath-lite expression, e.g. the
+// "tag[@attr='val']" in "/a/tag[@attr='val']".
+type pathSegment struct {
+	axis       string // "child", "descendant", "parent", or "self"
+	name       string // tag name, or "*" for any tag
+	predicates []predicate
+}
+
+// predicate narrows the nodes matched by a pathSegment: either a 1-based
+// sibling index ("tag[2]") or an attribute comparison ("tag[@id='1']").
+type predicate struct {
+	index    int // 0 means "no index predicate"
+	attrName string
+	attrOp   string // "=" or "!=", empty means "no attribute predicate"
+	attrVal  string
+}
+
+// parsePath splits a path such as "/people/person[@id='1']/../*" into
+// whether it is rooted (leading "/") and its sequence of segments.
+func parsePath(path string) (absolute bool, segments []pathSegment) {
+	i, n := 0, len(path)
+	if i < n && path[i] == '/' {
+		absolute = true
+		i++
+	}
+
+	for i < n {
+		axis := "child"
+		if i < n && path[i] == '/' {
+			axis = "descendant"
+			i++
+		}
+
+		start := i
+		for i < n && path[i] != '/' {
+			i++
+		}
+		token := path[start:i]
+		if i < n {
+			i++ // skip the separating '/'
+		}
+		if token == "" {
+			continue
+		}
+		segments = append(segments, parseSegment(token, axis))
+	}
+	return absolute, segments
+}
+
+func parseSegment(token, axis string) pathSegment {
+	switch token {
+	case "..":
+		return pathSegment{axis: "parent"}
+	case ".":
+		return pathSegment{axis: "self"}
+	}
+
+	name := token
+	var preds []predicate
+	if idx := strings.IndexByte(token, '['); idx >= 0 {
+		name = token[:idx]
+		rest := token[idx:]
+		for strings.HasPrefix(rest, "[") {
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				break
+			}
+			preds = append(preds, parsePredicate(rest[1:end]))
+			rest = rest[end+1:]
+		}
+	}
+	return pathSegment{axis: axis, name: name, predicates: preds}
+}
+
+func parsePredicate(raw string) predicate {
+	raw = strings.TrimSpace(raw)
+	if n, err := strconv.Atoi(raw); err == nil {
+		return predicate{index: n}
+	}
+
+	for _, op := range []string{"!=", "="} {
+		idx := strings.Index(raw, op)
+		if idx < 0 || !strings.HasPrefix(raw, "@") {
+			continue
+		}
+		name := strings.TrimSpace(raw[1:idx])
+		val := strings.Trim(strings.TrimSpace(raw[idx+len(op):]), `'"`)
+		return predicate{attrName: name, attrOp: op, attrVal: val}
+	}
+	return predicate{}
+}
+
+// FindElements evaluates a practical XPath subset against e: "/absolute"
+// and "./relative" paths, ".." to move to the parent, "//descendant"
+// lookups, "*" wildcards, and "tag[N]" / "tag[@attr='val']" (also "!=")
+// predicates.
+func (e *Element) FindElements(path string) []*Element {
+	absolute, segments := parsePath(path)
+	current := []*Element{e}
+	if absolute {
+		// A leading "/" addresses the root element itself, the way
+		// XPath addresses it relative to the document node: wrap it so
+		// the first segment's child:: step matches the root by name.
+		current = []*Element{{Children: []*Element{e.root()}}}
+	}
+
+	for _, seg := range segments {
+		var next []*Element
+		for _, el := range current {
+			next = append(next, seg.apply(el)...)
+		}
+		current = next
+	}
+	return current
+}
+
+func (seg pathSegment) apply(el *Element) []*Element {
+	switch seg.axis {
+	case "parent":
+		if el.parent == nil {
+			return nil
+		}
+		return []*Element{el.parent}
+	case "self":
+		return []*Element{el}
+	case "descendant":
+		var matches []*Element
+		var walk func(*Element)
+		walk = func(n *Element) {
+			for _, c := range n.Children {
+				if tagMatches(c.Tag, seg.name) {
+					matches = append(matches, c)
+				}
+				walk(c)
+			}
+		}
+		walk(el)
+		return applyPredicatesPerParent(matches, seg.predicates)
+	default: // "child"
+		var matches []*Element
+		for _, c := range el.Children {
+			if tagMatches(c.Tag, seg.name) {
+				matches = append(matches, c)
+			}
+		}
+		return applyPredicates(matches, seg.predicates)
+	}
+}
+
+// applyPredicatesPerParent applies preds the way "//tag[N]" is defined in
+// XPath: as shorthand for a child:: step repeated at every node of the
+// descendant-or-self sequence, so a positional predicate like [1] counts a
+// node's position among its own parent's matches, not across the whole,
+// flattened descendant result. Non-positional predicates filter the same
+// way regardless of grouping, so this only changes behavior for indices.
+func applyPredicatesPerParent(els []*Element, preds []predicate) []*Element {
+	var parents []*Element
+	groups := map[*Element][]*Element{}
+	for _, el := range els {
+		p := el.parent
+		if _, seen := groups[p]; !seen {
+			parents = append(parents, p)
+		}
+		groups[p] = append(groups[p], el)
+	}
+
+	kept := map[*Element]bool{}
+	for _, p := range parents {
+		for _, el := range applyPredicates(groups[p], preds) {
+			kept[el] = true
+		}
+	}
+	var result []*Element
+	for _, el := range els {
+		if kept[el] {
+			result = append(result, el)
+		}
+	}
+	return result
+}
+
+func applyPredicates(els []*Element, preds []predicate) []*Element {
+	for _, p := range preds {
+		var filtered []*Element
+		switch {
+		case p.index > 0:
+			if p.index <= len(els) {
+				filtered = []*Element{els[p.index-1]}
+			}
+		case p.attrName != "":
+			for _, el := range els {
+				val, ok := el.Attr(p.attrName)
+				match := ok && val == p.attrVal
+				if p.attrOp == "!=" {
+					match = !ok || val != p.attrVal
+				}
+				if match {
+					filtered = append(filtered, el)
+				}
+			}
+		default:
+			filtered = els
+		}
+		els = filtered
+	}
+	return els
+}